@@ -0,0 +1,52 @@
+package dynamostore
+
+import "context"
+
+// InvalidateSession deletes the session with the given id server-side, for
+// targeted logout. Unlike letting a cookie expire, this takes effect
+// immediately regardless of the client's MaxAge.
+//
+// Known limitation: this is a hard delete, not a tombstone. Put's
+// optimistic-concurrency check (dynamoBackend.Put) treats a missing item as
+// just as writable as a version match, so a client that already loaded the
+// session before it was invalidated can still call Save afterward and
+// recreate it with its old, pre-invalidation Values. Callers that need a
+// hard guarantee that an invalidated session can never come back should
+// additionally revoke whatever lets the client present that session id at
+// all (e.g. rotate the signing key, or track invalidated ids separately).
+func (s *DynamoStore) InvalidateSession(ctx context.Context, id string) error {
+	return s.backend.Delete(ctx, id)
+}
+
+// InvalidateAllForUser deletes every session belonging to userID, for "log
+// out everywhere" flows. It requires a backend that implements AdminBackend;
+// otherwise it returns ErrBackendNotSupported. The DynamoDB backend
+// implements it, but returns an error if user_index_enabled was not set when
+// the store was created.
+//
+// Known limitation: see InvalidateSession — this is a hard delete, so a
+// client holding a pre-invalidation copy of one of the deleted sessions can
+// still resurrect it with Save.
+func (s *DynamoStore) InvalidateAllForUser(ctx context.Context, userID string) error {
+	admin, ok := s.backend.(AdminBackend)
+	if !ok {
+		return ErrBackendNotSupported
+	}
+	return admin.InvalidateAllForUser(ctx, userID)
+}
+
+// PurgeExpired deletes every session whose TTL has passed and returns how
+// many were removed. It's meant for deployments where DynamoDB's own TTL
+// sweep (which can lag by up to ~48h) is unacceptable. It requires a backend
+// that implements AdminBackend; otherwise it returns ErrBackendNotSupported.
+//
+// Known limitation: see InvalidateSession — this shares the same hard-delete
+// caveat, though in practice it's a narrower window since every session
+// this deletes already has an expired TTL.
+func (s *DynamoStore) PurgeExpired(ctx context.Context) (int, error) {
+	admin, ok := s.backend.(AdminBackend)
+	if !ok {
+		return 0, ErrBackendNotSupported
+	}
+	return admin.PurgeExpired(ctx)
+}
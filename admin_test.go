@@ -0,0 +1,218 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/icrowley/fake"
+)
+
+func TestInvalidateSession(t *testing.T) {
+	store := NewStore(newMemoryBackend(), []byte("secret-key"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	if err := store.InvalidateSession(context.Background(), session.ID); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	existingSession, err := store.Get(req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if !existingSession.IsNew {
+		t.Error("expected session to have been invalidated, got existing session")
+	}
+}
+
+// TestInvalidateSessionDoesNotPreventResurrectionByStaleClient pins down a
+// documented limitation of InvalidateSession (see its doc comment): it's a
+// hard delete, not a tombstone, so a client that loaded the session before
+// it was invalidated can still call Save afterward and recreate it, because
+// Put's conditional write treats a missing item the same as a version
+// match. If this ever starts failing, either the limitation has been fixed
+// (update the doc comments) or a regression was introduced in Put's
+// conditional semantics.
+func TestInvalidateSessionDoesNotPreventResurrectionByStaleClient(t *testing.T) {
+	backend := &conditionalMemoryBackend{memoryBackend: *newMemoryBackend()}
+	store := NewStore(backend, []byte("secret-key"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	if err := store.InvalidateSession(context.Background(), session.ID); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	// session still holds the pre-invalidation state in memory, as a stale
+	// client would after losing the race with an admin-initiated logout.
+	session.Values["name"] = "still-alice"
+	if err := session.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	resurrected, err := store.Get(req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if resurrected.IsNew {
+		t.Fatal("expected the documented resurrection limitation to still hold; session was not resurrected")
+	}
+}
+
+func TestInvalidateAllForUserRequiresAdminBackend(t *testing.T) {
+	store := NewStore(newMemoryBackend(), []byte("secret-key"))
+
+	if err := store.InvalidateAllForUser(context.Background(), "user-1"); !errors.Is(err, ErrBackendNotSupported) {
+		t.Errorf("expected ErrBackendNotSupported; got %v", err)
+	}
+
+	if _, err := store.PurgeExpired(context.Background()); !errors.Is(err, ErrBackendNotSupported) {
+		t.Errorf("expected ErrBackendNotSupported; got %v", err)
+	}
+}
+
+func TestInvalidateAllForUserDeletesOnlyThatUsersSessions(t *testing.T) {
+	store, err := NewDynamoStore(map[string]interface{}{
+		"table":              fake.CharactersN(10),
+		"region":             "",
+		"endpoint":           "http://localhost:8000",
+		"ttl_enabled":        true,
+		"user_index_enabled": true,
+	}, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	newSession := func(userID string) (*sessions.Session, *httptest.ResponseRecorder) {
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+		res := httptest.NewRecorder()
+		session, err := store.New(req, "mysession")
+		if err != nil {
+			t.Fatalf("expected nil; got %v", err)
+		}
+		session.Values["user_id"] = userID
+		if err := session.Save(req, res); err != nil {
+			t.Fatalf("expected nil; got %v", err)
+		}
+		return session, res
+	}
+
+	_, aliceRes := newSession("alice")
+	_, bobRes := newSession("bob")
+
+	if err := store.InvalidateAllForUser(context.Background(), "alice"); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	aliceReq, _ := http.NewRequest("GET", "http://localhost/", nil)
+	for _, c := range aliceRes.Result().Cookies() {
+		aliceReq.AddCookie(c)
+	}
+	aliceAfter, err := store.Get(aliceReq, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if !aliceAfter.IsNew {
+		t.Error("expected alice's session to have been invalidated, got existing session")
+	}
+
+	bobReq, _ := http.NewRequest("GET", "http://localhost/", nil)
+	for _, c := range bobRes.Result().Cookies() {
+		bobReq.AddCookie(c)
+	}
+	bobAfter, err := store.Get(bobReq, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if bobAfter.IsNew {
+		t.Error("expected bob's session to be untouched, got invalidated")
+	}
+}
+
+func TestPurgeExpiredDeletesOnlyExpiredSessions(t *testing.T) {
+	store, err := NewDynamoStore(map[string]interface{}{
+		"table":       fake.CharactersN(10),
+		"region":      "",
+		"endpoint":    "http://localhost:8000",
+		"ttl_enabled": true,
+	}, []byte("secret-key"))
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	expired, err := store.New(req, "expired")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	expired.Options.MaxAge = 1
+	expired.Values["name"] = "expired"
+	if err := expired.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	live, err := store.New(req, "live")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	live.Values["name"] = "live"
+	liveRes := httptest.NewRecorder()
+	if err := live.Save(req, liveRes); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	time.Sleep(2 * time.Second)
+
+	purged, err := store.PurgeExpired(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if purged < 1 {
+		t.Errorf("expected at least 1 session purged; got %d", purged)
+	}
+
+	liveReq, _ := http.NewRequest("GET", "http://localhost/", nil)
+	for _, c := range liveRes.Result().Cookies() {
+		liveReq.AddCookie(c)
+	}
+	liveAfter, err := store.Get(liveReq, "live")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if liveAfter.IsNew {
+		t.Error("expected live session to survive PurgeExpired, got purged")
+	}
+}
@@ -0,0 +1,75 @@
+package dynamostore
+
+import "context"
+
+// Session is the backend-agnostic record persisted for a single gorilla
+// session. Every SessionBackend implementation reads and writes this shape,
+// regardless of what storage engine sits underneath it.
+// Struct tags carry both json (used by backends that serialize to JSON) and
+// dynamodbav (used by attributevalue.MarshalMap/UnmarshalMap, which ignores
+// json tags and falls back to the bare Go field name without them) so both
+// encodings agree on attribute names. The DynamoDB table's hash key is the
+// lowercase "id" attribute, so dynamodbav:"id" in particular is load-bearing.
+type Session struct {
+	// Identifier for session values
+	ID string `json:"id" dynamodbav:"id"`
+	// Encoded session values
+	Data string `json:"data" dynamodbav:"data"`
+	// Unix timestamp indicating when the session values were modified
+	ModifiedAt int64 `json:"modified_at" dynamodbav:"modified_at"`
+	// TTL is the unix timestamp after which the session is considered
+	// expired. Zero means the session never expires.
+	TTL int64 `json:"ttl" dynamodbav:"ttl"`
+	// Version is the optimistic-concurrency counter. Put receives the
+	// version the caller last observed (0 for a session that doesn't
+	// exist yet) and, on success, bumps it by one in place so the caller
+	// can carry the new value into its next write.
+	Version int64 `json:"version" dynamodbav:"version"`
+	// UserID is populated from session.Values["user_id"] when set, so
+	// backends that index on it can support invalidating every session
+	// belonging to a user.
+	UserID string `json:"user_id,omitempty" dynamodbav:"user_id,omitempty"`
+}
+
+// SessionBackend is the persistence layer used by DynamoStore. Implementing
+// this interface lets DynamoStore's cookie/codec/registry plumbing be reused
+// against storage engines other than DynamoDB (file, in-memory, Redis,
+// Postgres, ...), the same way gorilla/sessions' FilesystemStore and
+// gorilla/sessions/redistore.RediStore share the cookie handling in
+// sessions.Store.
+type SessionBackend interface {
+	// Get fetches the session with the given id. Implementations should
+	// return an error if no such session exists.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Put creates or overwrites the session with the given id. session.Version
+	// is the version the caller last observed; an implementation that
+	// supports optimistic concurrency should reject the write with
+	// ErrSessionConflict if the stored version has since moved on, and
+	// otherwise persist session.Version+1. Implementations that don't
+	// support optimistic concurrency may ignore Version and overwrite
+	// unconditionally.
+	Put(ctx context.Context, session *Session) error
+	// Delete removes the session with the given id. Deleting a session
+	// that does not exist should not be treated as an error.
+	Delete(ctx context.Context, id string) error
+	// BatchGet fetches multiple sessions at once. Implementations that
+	// cannot batch natively may fall back to sequential Gets. Ids with no
+	// matching session are simply absent from the returned map.
+	BatchGet(ctx context.Context, ids []string) (map[string]*Session, error)
+	// BatchPut writes multiple sessions at once. Implementations that
+	// cannot batch natively may fall back to sequential Puts.
+	BatchPut(ctx context.Context, sessions []*Session) error
+}
+
+// AdminBackend is an optional extension of SessionBackend for backends that
+// support server-side bulk session management beyond basic CRUD. DynamoStore
+// type-asserts its backend for this interface before calling
+// InvalidateAllForUser or PurgeExpired, so backends that don't implement it
+// simply report ErrBackendNotSupported for those two operations.
+type AdminBackend interface {
+	// InvalidateAllForUser deletes every session belonging to userID.
+	InvalidateAllForUser(ctx context.Context, userID string) error
+	// PurgeExpired deletes every session whose TTL has passed and returns
+	// how many were removed.
+	PurgeExpired(ctx context.Context) (int, error)
+}
@@ -0,0 +1,139 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+)
+
+// memoryBackend is a minimal SessionBackend used to exercise DynamoStore
+// against something other than DynamoDB.
+type memoryBackend struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{sessions: make(map[string]*Session)}
+}
+
+func (b *memoryBackend) Get(ctx context.Context, id string) (*Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	session, ok := b.sessions[id]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	return session, nil
+}
+
+func (b *memoryBackend) Put(ctx context.Context, session *Session) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sessions[session.ID] = session
+	return nil
+}
+
+func (b *memoryBackend) Delete(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, id)
+	return nil
+}
+
+func (b *memoryBackend) BatchGet(ctx context.Context, ids []string) (map[string]*Session, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make(map[string]*Session, len(ids))
+	for _, id := range ids {
+		if session, ok := b.sessions[id]; ok {
+			result[id] = session
+		}
+	}
+	return result, nil
+}
+
+func (b *memoryBackend) BatchPut(ctx context.Context, sessions []*Session) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, session := range sessions {
+		b.sessions[session.ID] = session
+	}
+	return nil
+}
+
+func TestNewStoreWithMemoryBackend(t *testing.T) {
+	store := NewStore(newMemoryBackend(), []byte("secret-key"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "mysession")
+	if err != nil {
+		t.Errorf("expected nil; got %v", err)
+		return
+	}
+	if !session.IsNew {
+		t.Error("expected new session, got existing session")
+		return
+	}
+
+	session.Values["name"] = "bob"
+	if err = session.Save(req, res); err != nil {
+		t.Errorf("expected nil; got %v", err)
+		return
+	}
+
+	req.AddCookie(res.Result().Cookies()[0])
+	existingSession, err := store.Get(req, "mysession")
+	if err != nil {
+		t.Errorf("expected nil; got %v", err)
+		return
+	}
+	if existingSession.IsNew {
+		t.Error("expected existing session, got new session")
+		return
+	}
+	if existingSession.Values["name"] != "bob" {
+		t.Error("session values didn't match")
+		return
+	}
+}
+
+// TestSessionAttributeValueRoundTrip guards against Session's struct tags
+// drifting out of sync with attributevalue.MarshalMap's expectations.
+// attributevalue ignores json tags and falls back to the bare Go field name
+// without a dynamodbav tag of its own, which would marshal ID to "ID" and
+// leave the table's declared "id" hash key missing from every item.
+func TestSessionAttributeValueRoundTrip(t *testing.T) {
+	session := &Session{
+		ID:         "session-id",
+		Data:       "encoded-data",
+		ModifiedAt: 1700000000,
+		TTL:        1700003600,
+		Version:    3,
+		UserID:     "user-1",
+	}
+
+	item, err := attributevalue.MarshalMap(session)
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	if _, ok := item["id"]; !ok {
+		t.Fatalf(`expected marshaled item to have an "id" attribute, matching the table's hash key; got %v`, item)
+	}
+
+	var decoded Session
+	if err := attributevalue.UnmarshalMap(item, &decoded); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if decoded != *session {
+		t.Errorf("expected round-tripped session to equal original; got %+v, want %+v", decoded, *session)
+	}
+}
@@ -0,0 +1,105 @@
+package dynamostore
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// GetMulti fetches several named sessions for a single request in one
+// round-trip to the backend, instead of issuing a separate Get per cookie.
+// Names whose cookie is missing, undecodable, or whose session has expired
+// come back as new sessions, same as Get would return for them individually.
+func (s *DynamoStore) GetMulti(r *http.Request, names ...string) (map[string]*sessions.Session, error) {
+	result := make(map[string]*sessions.Session, len(names))
+	idToName := make(map[string]string, len(names))
+	ids := make([]string, 0, len(names))
+
+	for _, name := range names {
+		session := sessions.NewSession(s, name)
+		opts := *s.Options
+		session.Options = &opts
+		session.IsNew = true
+		result[name] = session
+
+		c, err := r.Cookie(name)
+		if err != nil {
+			continue
+		}
+		if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+			continue
+		}
+		idToName[session.ID] = name
+		ids = append(ids, session.ID)
+	}
+
+	sessionObjs, err := s.backend.BatchGet(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, sessionObj := range sessionObjs {
+		if sessionObj.TTL > 0 && sessionObj.TTL < time.Now().Unix() {
+			continue
+		}
+
+		session := result[idToName[id]]
+		if err := securecookie.DecodeMulti(session.Name(), sessionObj.Data, &session.Values, s.Codecs...); err != nil {
+			continue
+		}
+		session.IsNew = false
+		setSessionVersion(session, sessionObj.Version)
+	}
+
+	return result, nil
+}
+
+// preloadContextKey is the context key under which Preload stashes its
+// cache, so it rides along on the same ctx the caller later passes to
+// GetContext/NewContext instead of living on the store itself.
+type preloadContextKey struct{}
+
+// preloadCache holds the sessions fetched by a single Preload call, keyed by
+// session id. Entries are consumed (and removed) the first time load()
+// reads them.
+type preloadCache struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// takePreloaded returns and removes the preloaded session for id, if ctx
+// carries a preload cache and has one.
+func takePreloaded(ctx context.Context, id string) (*Session, bool) {
+	cache, ok := ctx.Value(preloadContextKey{}).(*preloadCache)
+	if !ok {
+		return nil, false
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	sessionObj, ok := cache.sessions[id]
+	if ok {
+		delete(cache.sessions, id)
+	}
+	return sessionObj, ok
+}
+
+// Preload fetches the given session ids in a single backend round-trip and
+// returns a context carrying them. Pass the returned context to GetContext/
+// NewContext for the rest of the request and a matching Get/New call is
+// served from memory instead of issuing its own backend round-trip. The
+// cache is scoped to the returned context, so it never outlives the request
+// and can't be served stale by a concurrent request preloading the same id.
+func (s *DynamoStore) Preload(ctx context.Context, ids ...string) (context.Context, error) {
+	sessionObjs, err := s.backend.BatchGet(ctx, ids)
+	if err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, preloadContextKey{}, &preloadCache{sessions: sessionObjs}), nil
+}
@@ -0,0 +1,110 @@
+package dynamostore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getCountingBackend wraps memoryBackend to count Get calls, so tests can
+// assert a preloaded session is actually served from the cache instead of
+// hitting the backend again.
+type getCountingBackend struct {
+	memoryBackend
+	getCount int
+}
+
+func (b *getCountingBackend) Get(ctx context.Context, id string) (*Session, error) {
+	b.getCount++
+	return b.memoryBackend.Get(ctx, id)
+}
+
+func TestGetMulti(t *testing.T) {
+	store := NewStore(newMemoryBackend(), []byte("secret-key"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	alice, err := store.New(req, "alice")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	alice.Values["name"] = "alice"
+	if err := alice.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	sessions, err := store.GetMulti(req, "alice", "bob")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	if sessions["alice"].IsNew {
+		t.Error("expected alice session to be existing, got new")
+	}
+	if sessions["alice"].Values["name"] != "alice" {
+		t.Error("alice session values didn't match")
+	}
+	if !sessions["bob"].IsNew {
+		t.Error("expected bob session to be new, got existing")
+	}
+}
+
+func TestPreloadIsConsumedByGetContextWithoutASecondBackendGet(t *testing.T) {
+	backend := &getCountingBackend{memoryBackend: *newMemoryBackend()}
+	store := NewStore(backend, []byte("secret-key"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	ctx, err := store.Preload(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	preloaded, err := store.GetContext(ctx, req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if preloaded.IsNew {
+		t.Error("expected preloaded session to be existing, got new")
+	}
+	if preloaded.Values["name"] != "alice" {
+		t.Error("preloaded session values didn't match")
+	}
+	if backend.getCount != 0 {
+		t.Errorf("expected GetContext to be served from the preload cache with no backend Get; got %d Get calls", backend.getCount)
+	}
+
+	// Without a preloaded context, the same call falls back to the backend.
+	// Use a fresh request so gorilla's per-request session registry (which
+	// GetRegistry stashes on req's context) doesn't just hand back the
+	// already-cached session from the call above.
+	fallbackReq, _ := http.NewRequest("GET", "http://localhost/", nil)
+	for _, c := range res.Result().Cookies() {
+		fallbackReq.AddCookie(c)
+	}
+	if _, err := store.GetContext(context.Background(), fallbackReq, "mysession"); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if backend.getCount != 1 {
+		t.Errorf("expected a single backend Get once the preload cache is bypassed; got %d", backend.getCount)
+	}
+}
@@ -0,0 +1,149 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamoDBBatchWriteLimit is the maximum number of items DynamoDB accepts in
+// a single BatchWriteItem call.
+const dynamoDBBatchWriteLimit = 25
+
+// dynamoDBBatchWriteMaxRetries bounds the number of times batchDelete
+// retries UnprocessedItems before giving up.
+const dynamoDBBatchWriteMaxRetries = 5
+
+// InvalidateAllForUser deletes every session indexed under userID in the
+// user_id-index GSI. It requires the backend to have been created with
+// user_index_enabled set.
+func (b *dynamoBackend) InvalidateAllForUser(ctx context.Context, userID string) error {
+	if !b.userIndexEnabled {
+		return errors.New("dynamostore: user_index_enabled was not set when the store was created")
+	}
+
+	paginator := dynamodb.NewQueryPaginator(b.client, &dynamodb.QueryInput{
+		TableName:              aws.String(b.table),
+		IndexName:              aws.String(userIDIndexName),
+		KeyConditionExpression: aws.String("user_id = :uid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+		ProjectionExpression: aws.String("id"),
+	})
+
+	var ids []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			var session Session
+			if err := attributevalue.UnmarshalMap(item, &session); err != nil {
+				return err
+			}
+			ids = append(ids, session.ID)
+		}
+	}
+
+	return b.batchDelete(ctx, ids)
+}
+
+// PurgeExpired scans the table for sessions whose TTL has already passed and
+// deletes them, returning how many were removed. It's meant for deployments
+// where DynamoDB's own TTL sweep (which can lag by up to ~48h) is
+// unacceptable.
+func (b *dynamoBackend) PurgeExpired(ctx context.Context) (int, error) {
+	now := time.Now().Unix()
+
+	paginator := dynamodb.NewScanPaginator(b.client, &dynamodb.ScanInput{
+		TableName:            aws.String(b.table),
+		FilterExpression:     aws.String("#ttl > :zero AND #ttl < :now"),
+		ProjectionExpression: aws.String("id"),
+		// ttl is a DynamoDB reserved word, so it must be referenced by name.
+		ExpressionAttributeNames: map[string]string{"#ttl": "ttl"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+			":now":  &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		},
+	})
+
+	var ids []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, item := range page.Items {
+			var session Session
+			if err := attributevalue.UnmarshalMap(item, &session); err != nil {
+				return 0, err
+			}
+			ids = append(ids, session.ID)
+		}
+	}
+
+	if err := b.batchDelete(ctx, ids); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// batchDelete removes ids in chunks of up to 25 (DynamoDB's BatchWriteItem
+// limit), retrying any UnprocessedItems with exponential backoff, the same
+// way batchGetChunk retries UnprocessedKeys.
+func (b *dynamoBackend) batchDelete(ctx context.Context, ids []string) error {
+	for start := 0; start < len(ids); start += dynamoDBBatchWriteLimit {
+		end := start + dynamoDBBatchWriteLimit
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		writeRequests := make([]types.WriteRequest, len(ids[start:end]))
+		for i, id := range ids[start:end] {
+			writeRequests[i] = types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{
+					Key: map[string]types.AttributeValue{
+						"id": &types.AttributeValueMemberS{Value: id},
+					},
+				},
+			}
+		}
+
+		requestItems := map[string][]types.WriteRequest{b.table: writeRequests}
+		backoff := 50 * time.Millisecond
+		for attempt := 0; ; attempt++ {
+			result, err := b.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return err
+			}
+			if len(result.UnprocessedItems) == 0 {
+				break
+			}
+			if attempt >= dynamoDBBatchWriteMaxRetries {
+				return fmt.Errorf("dynamostore: %d items left unprocessed after %d retries", len(result.UnprocessedItems[b.table]), attempt)
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+			requestItems = result.UnprocessedItems
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,384 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// userIDIndexName is the name of the GSI created on the user_id attribute
+// when user_index_enabled is set, used by InvalidateAllForUser.
+const userIDIndexName = "user_id-index"
+
+// dynamoBackend is the SessionBackend implementation backed by Amazon
+// DynamoDB. It is the backend NewDynamoStore wires up by default.
+type dynamoBackend struct {
+	table            string
+	ttlEnabled       bool
+	userIndexEnabled bool
+	client           *dynamodb.Client
+}
+
+// dynamoBackendConfig holds the subset of NewDynamoStore's config map that
+// is specific to the DynamoDB backend.
+type dynamoBackendConfig struct {
+	table               string
+	region              string
+	endpoint            string
+	ttlEnabled          bool
+	userIndexEnabled    bool
+	readCapacity        int64
+	writeCapacity       int64
+	billingMode         string
+	sseEnabled          bool
+	pointInTimeRecovery bool
+}
+
+// newDynamoBackend loads AWS config, creates the DynamoDB client and ensures
+// the backing table exists.
+func newDynamoBackend(ctx context.Context, cfg dynamoBackendConfig) (*dynamoBackend, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.region),
+	}
+	if cfg.endpoint != "" {
+		resolver := aws.EndpointResolverWithOptionsFunc(
+			func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: cfg.endpoint}, nil
+			})
+		opts = append(opts, config.WithEndpointResolverWithOptions(resolver))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := dynamodb.NewFromConfig(awsCfg)
+	if err := createTableIfNotExists(ctx, client, tableConfig{
+		table:               cfg.table,
+		readCapacity:        cfg.readCapacity,
+		writeCapacity:       cfg.writeCapacity,
+		ttlEnabled:          cfg.ttlEnabled,
+		userIndexEnabled:    cfg.userIndexEnabled,
+		billingMode:         cfg.billingMode,
+		sseEnabled:          cfg.sseEnabled,
+		pointInTimeRecovery: cfg.pointInTimeRecovery,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &dynamoBackend{
+		table:            cfg.table,
+		ttlEnabled:       cfg.ttlEnabled,
+		userIndexEnabled: cfg.userIndexEnabled,
+		client:           client,
+	}, nil
+}
+
+// Get reads the session from dynamoDB.
+func (b *dynamoBackend) Get(ctx context.Context, id string) (*Session, error) {
+	input := &dynamodb.GetItemInput{
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		ConsistentRead: aws.Bool(true),
+		TableName:      aws.String(b.table),
+	}
+
+	result, err := b.client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, errors.New("dynamostore: session not found")
+	}
+
+	var session Session
+	if err := attributevalue.UnmarshalMap(result.Item, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Put writes the session into dynamoDB, guarding against lost updates with a
+// conditional write: the item is only written if it doesn't exist yet, or if
+// its stored version still matches session.Version (the version this caller
+// last observed). On success session.Version is bumped to reflect the
+// version now stored. A concurrent writer that already moved the version on
+// causes this to fail with ErrSessionConflict instead of overwriting it.
+func (b *dynamoBackend) Put(ctx context.Context, session *Session) error {
+	expectedVersion := session.Version
+	session.Version = expectedVersion + 1
+
+	item, err := attributevalue.MarshalMap(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.client.PutItem(ctx, &dynamodb.PutItemInput{
+		Item:                item,
+		TableName:           aws.String(b.table),
+		ConditionExpression: aws.String("attribute_not_exists(id) OR version = :v"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			session.Version = expectedVersion
+			return ErrSessionConflict
+		}
+		session.Version = expectedVersion
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the session from dynamoDB.
+func (b *dynamoBackend) Delete(ctx context.Context, id string) error {
+	_, err := b.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+		TableName: aws.String(b.table),
+	})
+	return err
+}
+
+// dynamoDBBatchGetLimit is the maximum number of keys DynamoDB accepts in a
+// single BatchGetItem call.
+const dynamoDBBatchGetLimit = 100
+
+// dynamoDBBatchGetMaxRetries bounds the number of times BatchGet retries
+// UnprocessedKeys before giving up.
+const dynamoDBBatchGetMaxRetries = 5
+
+// BatchGet fetches multiple sessions, transparently splitting ids into
+// batches of up to 100 (DynamoDB's BatchGetItem limit) and retrying any
+// UnprocessedKeys with exponential backoff.
+func (b *dynamoBackend) BatchGet(ctx context.Context, ids []string) (map[string]*Session, error) {
+	sessions := make(map[string]*Session, len(ids))
+
+	for start := 0; start < len(ids); start += dynamoDBBatchGetLimit {
+		end := start + dynamoDBBatchGetLimit
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := b.batchGetChunk(ctx, ids[start:end], sessions); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, nil
+}
+
+// batchGetChunk fetches a single DynamoDB BatchGetItem-sized chunk of ids,
+// retrying any UnprocessedKeys with exponential backoff, and stores the
+// results into sessions.
+func (b *dynamoBackend) batchGetChunk(ctx context.Context, ids []string, sessions map[string]*Session) error {
+	keys := make([]map[string]types.AttributeValue, len(ids))
+	for i, id := range ids {
+		keys[i] = map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		}
+	}
+
+	requestItems := map[string]types.KeysAndAttributes{
+		b.table: {
+			Keys:           keys,
+			ConsistentRead: aws.Bool(true),
+		},
+	}
+
+	backoff := 50 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		result, err := b.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range result.Responses[b.table] {
+			var session Session
+			if err := attributevalue.UnmarshalMap(item, &session); err != nil {
+				return err
+			}
+			sessions[session.ID] = &session
+		}
+
+		unprocessed, ok := result.UnprocessedKeys[b.table]
+		if !ok || len(unprocessed.Keys) == 0 {
+			return nil
+		}
+		if attempt >= dynamoDBBatchGetMaxRetries {
+			return fmt.Errorf("dynamostore: %d keys left unprocessed after %d retries", len(unprocessed.Keys), attempt)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		requestItems = map[string]types.KeysAndAttributes{b.table: unprocessed}
+	}
+}
+
+// BatchPut writes multiple sessions with a single BatchWriteItem call.
+// sessions must not exceed DynamoDB's 25-item batch limit.
+func (b *dynamoBackend) BatchPut(ctx context.Context, sessions []*Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	writeRequests := make([]types.WriteRequest, len(sessions))
+	for i, session := range sessions {
+		item, err := attributevalue.MarshalMap(session)
+		if err != nil {
+			return err
+		}
+		writeRequests[i] = types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: item},
+		}
+	}
+
+	_, err := b.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{
+			b.table: writeRequests,
+		},
+	})
+	return err
+}
+
+// Billing modes accepted by the billing_mode config key, mirroring
+// Terraform's aws_dynamodb_table billing_mode attribute.
+const (
+	BillingModeProvisioned   = "PROVISIONED"
+	BillingModePayPerRequest = "PAY_PER_REQUEST"
+)
+
+// tableConfig holds everything configureTable needs to create and tune the
+// session table.
+type tableConfig struct {
+	table               string
+	readCapacity        int64
+	writeCapacity       int64
+	ttlEnabled          bool
+	userIndexEnabled    bool
+	billingMode         string
+	sseEnabled          bool
+	pointInTimeRecovery bool
+}
+
+// createTableIfNotExists creates a DynamoDB table with a given
+// DynamoDB client. If the table already exists, it is not being reconfigured.
+func createTableIfNotExists(ctx context.Context, client *dynamodb.Client, cfg tableConfig) error {
+	_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(cfg.table),
+	})
+
+	var notFoundErr *types.ResourceNotFoundException
+	if errors.As(err, &notFoundErr) {
+		return configureTable(ctx, client, cfg)
+	}
+	return err
+}
+
+func configureTable(ctx context.Context, client *dynamodb.Client, cfg tableConfig) error {
+	input := &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{{
+			AttributeName: aws.String("id"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}},
+		KeySchema: []types.KeySchemaElement{{
+			AttributeName: aws.String("id"),
+			KeyType:       types.KeyTypeHash,
+		}},
+		TableName: aws.String(cfg.table),
+	}
+
+	if cfg.billingMode == BillingModePayPerRequest {
+		input.BillingMode = types.BillingModePayPerRequest
+	} else {
+		input.BillingMode = types.BillingModeProvisioned
+		input.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(cfg.readCapacity),
+			WriteCapacityUnits: aws.Int64(cfg.writeCapacity),
+		}
+	}
+
+	if cfg.sseEnabled {
+		input.SSESpecification = &types.SSESpecification{Enabled: aws.Bool(true)}
+	}
+
+	if cfg.userIndexEnabled {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String("user_id"),
+			AttributeType: types.ScalarAttributeTypeS,
+		})
+
+		gsi := types.GlobalSecondaryIndex{
+			IndexName: aws.String(userIDIndexName),
+			KeySchema: []types.KeySchemaElement{{
+				AttributeName: aws.String("user_id"),
+				KeyType:       types.KeyTypeHash,
+			}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}
+		if input.BillingMode != types.BillingModePayPerRequest {
+			gsi.ProvisionedThroughput = &types.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(cfg.readCapacity),
+				WriteCapacityUnits: aws.Int64(cfg.writeCapacity),
+			}
+		}
+		input.GlobalSecondaryIndexes = []types.GlobalSecondaryIndex{gsi}
+	}
+
+	if _, err := client.CreateTable(ctx, input); err != nil {
+		return err
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(cfg.table),
+	}, 5*time.Minute); err != nil {
+		return err
+	}
+
+	if cfg.ttlEnabled {
+		if _, err := client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(cfg.table),
+			TimeToLiveSpecification: &types.TimeToLiveSpecification{
+				AttributeName: aws.String("ttl"),
+				Enabled:       aws.Bool(true),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	if cfg.pointInTimeRecovery {
+		if _, err := client.UpdateContinuousBackups(ctx, &dynamodb.UpdateContinuousBackupsInput{
+			TableName: aws.String(cfg.table),
+			PointInTimeRecoverySpecification: &types.PointInTimeRecoverySpecification{
+				PointInTimeRecoveryEnabled: aws.Bool(true),
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,17 +1,13 @@
 package dynamostore
 
 import (
+	"context"
 	"encoding/base32"
 	"errors"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 )
@@ -31,29 +27,40 @@ const (
 	DefaultTTLEnabled = true
 )
 
-// DynamoStore stores sessions in dynamoDB.
+// DynamoStore stores gorilla sessions behind a pluggable SessionBackend.
+// NewDynamoStore wires up the DynamoDB backend by default; NewStore accepts
+// any other SessionBackend implementation (file, in-memory, Redis,
+// Postgres, ...).
 type DynamoStore struct {
-	table      string
-	ttlEnabled bool
-	client     *dynamodb.DynamoDB
-	Codecs     []securecookie.Codec
-	Options    *sessions.Options // default configuration
+	backend SessionBackend
+	Codecs  []securecookie.Codec
+	Options *sessions.Options // default configuration
+
+	// TTLEnabled controls whether save() writes a TTL attribute on each
+	// session. It's independent of the backend's own ttl_enabled (which
+	// only governs whether DynamoDB's TTL sweep is turned on for the
+	// table): a caller that sets ttl_enabled: false doesn't want sessions
+	// to expire at all, so the TTL attribute shouldn't be written even
+	// though load() would otherwise honor it.
+	TTLEnabled bool
 }
 
-// Session object stored in dynamoDB
-type Session struct {
-	// Identifier for session values
-	ID string `json:"id"`
-	// Encoded session values
-	Data string `json:"data"`
-	// Unix timestamp indicating when the session values were modified
-	ModifiedAt int64 `json:"modified_at"`
-	// TTL field for table
-	TTL int64 `json:"ttl"`
+// NewStore creates a DynamoStore backed by the given SessionBackend. Use
+// this instead of NewDynamoStore to plug in a backend other than DynamoDB.
+func NewStore(backend SessionBackend, keyPairs ...[]byte) *DynamoStore {
+	return &DynamoStore{
+		backend: backend,
+		Codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: DefaultMaxAge,
+		},
+		TTLEnabled: DefaultTTLEnabled,
+	}
 }
 
-// NewDynamoStore creates the dynamoDB store from given configuration
-// config parameters expects the following keys:
+// NewDynamoStore creates a DynamoStore backed by DynamoDB from the given
+// configuration. config parameters expects the following keys:
 //
 // 1. table for dynamoDB table to store the session. (type: string)
 //
@@ -69,73 +76,91 @@ type Session struct {
 //
 // 7. ttl_enabled for enabling ttl on the table. (type: bool)
 //
+// 8. billing_mode for the table's billing mode, "PROVISIONED" or
+// "PAY_PER_REQUEST". (type: string, default: "PROVISIONED")
+//
+// 9. sse_enabled for enabling server-side encryption on the table. (type: bool)
+//
+// 10. point_in_time_recovery for enabling point-in-time recovery on the table. (type: bool)
+//
+// 11. user_index_enabled for creating a user_id-index GSI used by
+// InvalidateAllForUser. (type: bool)
+//
 // If any of the keys is missing or wrong type is provided for the key, corresponding default value for the key will be used.
 //
 // See https://github.com/gorilla/sessions/blob/master/store.go for detailed information on what keyPairs does.
-func NewDynamoStore(config map[string]interface{}, keyPairs ...[]byte) (*DynamoStore, error) {
-
+func NewDynamoStore(cfg map[string]interface{}, keyPairs ...[]byte) (*DynamoStore, error) {
 	var (
-		table         string
-		region        string
-		endpoint      string
-		ttlEnabled    bool
-		readCapacity  int64
-		writeCapacity int64
-		maxAge        int64
-		ok            bool
+		table               string
+		region              string
+		endpoint            string
+		billingMode         string
+		ttlEnabled          bool
+		sseEnabled          bool
+		pointInTimeRecovery bool
+		userIndexEnabled    bool
+		readCapacity        int64
+		writeCapacity       int64
+		maxAge              int64
+		ok                  bool
 	)
 
-	if table, ok = config["table"].(string); !ok || table == "" {
+	if table, ok = cfg["table"].(string); !ok || table == "" {
 		table = DefaultDynamoDBTableName
 	}
 
-	if readCapacity, ok = config["read_capacity"].(int64); !ok || readCapacity <= 0 {
+	if readCapacity, ok = cfg["read_capacity"].(int64); !ok || readCapacity <= 0 {
 		readCapacity = DefaultDynamoDBReadCapacity
 	}
 
-	if writeCapacity, ok = config["write_capacity"].(int64); !ok || writeCapacity <= 0 {
+	if writeCapacity, ok = cfg["write_capacity"].(int64); !ok || writeCapacity <= 0 {
 		writeCapacity = DefaultDynamoDBWriteCapacity
 	}
 
-	if maxAge, ok = config["max_age"].(int64); !ok || maxAge <= 0 {
+	if maxAge, ok = cfg["max_age"].(int64); !ok || maxAge <= 0 {
 		maxAge = DefaultMaxAge
 	}
 
-	if region, ok = config["region"].(string); !ok || region == "" {
+	if region, ok = cfg["region"].(string); !ok || region == "" {
 		region = DefaultDynamoDBRegion
 	}
 
-	if ttlEnabled, ok = config["ttl_enabled"].(bool); !ok {
+	if ttlEnabled, ok = cfg["ttl_enabled"].(bool); !ok {
 		ttlEnabled = DefaultTTLEnabled
 	}
 
-	if endpoint, ok = config["endpoint"].(string); !ok {
+	if endpoint, ok = cfg["endpoint"].(string); !ok {
 		endpoint = ""
 	}
 
-	session, err := session.NewSession(&aws.Config{
-		Region:   aws.String(region),
-		Endpoint: aws.String(endpoint),
-	})
-	if err != nil {
-		return nil, err
+	if billingMode, ok = cfg["billing_mode"].(string); !ok || billingMode == "" {
+		billingMode = BillingModeProvisioned
 	}
 
-	client := dynamodb.New(session)
-	if err := createTableIfNotExists(client, table, readCapacity, writeCapacity, ttlEnabled); err != nil {
+	sseEnabled, _ = cfg["sse_enabled"].(bool)
+	pointInTimeRecovery, _ = cfg["point_in_time_recovery"].(bool)
+	userIndexEnabled, _ = cfg["user_index_enabled"].(bool)
+
+	backend, err := newDynamoBackend(context.Background(), dynamoBackendConfig{
+		table:               table,
+		region:              region,
+		endpoint:            endpoint,
+		ttlEnabled:          ttlEnabled,
+		userIndexEnabled:    userIndexEnabled,
+		readCapacity:        readCapacity,
+		writeCapacity:       writeCapacity,
+		billingMode:         billingMode,
+		sseEnabled:          sseEnabled,
+		pointInTimeRecovery: pointInTimeRecovery,
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return &DynamoStore{
-		table:      table,
-		ttlEnabled: ttlEnabled,
-		client:     client,
-		Codecs:     securecookie.CodecsFromPairs(keyPairs...),
-		Options: &sessions.Options{
-			Path:   "/",
-			MaxAge: int(maxAge),
-		},
-	}, nil
+	store := NewStore(backend, keyPairs...)
+	store.Options.MaxAge = int(maxAge)
+	store.TTLEnabled = ttlEnabled
+	return store, nil
 }
 
 // Get returns a session for the given name after adding it to the registry.
@@ -146,7 +171,13 @@ func NewDynamoStore(config map[string]interface{}, keyPairs ...[]byte) (*DynamoS
 // It returns a new session and an error if the session exists but could
 // not be decoded.
 func (s *DynamoStore) Get(r *http.Request, name string) (*sessions.Session, error) {
-	return sessions.GetRegistry(r).Get(s, name)
+	return s.GetContext(r.Context(), r, name)
+}
+
+// GetContext is the context-aware variant of Get. The given context governs
+// the underlying backend lookup when an existing session is loaded.
+func (s *DynamoStore) GetContext(ctx context.Context, r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(&contextStore{s, ctx}, name)
 }
 
 // New returns a session for the given name without adding it to the registry.
@@ -154,6 +185,12 @@ func (s *DynamoStore) Get(r *http.Request, name string) (*sessions.Session, erro
 // The difference between New() and Get() is that calling New() twice will
 // decode the session data twice, while Get() registers and reuses the same
 func (s *DynamoStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return s.NewContext(r.Context(), r, name)
+}
+
+// NewContext is the context-aware variant of New. The given context governs
+// the underlying backend lookup when an existing session is loaded.
+func (s *DynamoStore) NewContext(ctx context.Context, r *http.Request, name string) (*sessions.Session, error) {
 	session := sessions.NewSession(s, name)
 	opts := *s.Options
 	session.Options = &opts
@@ -162,7 +199,7 @@ func (s *DynamoStore) New(r *http.Request, name string) (*sessions.Session, erro
 	if c, errCookie := r.Cookie(name); errCookie == nil {
 		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...)
 		if err == nil {
-			err = s.load(session)
+			err = s.load(ctx, session)
 			if err == nil {
 				session.IsNew = false
 			} else {
@@ -175,8 +212,14 @@ func (s *DynamoStore) New(r *http.Request, name string) (*sessions.Session, erro
 
 // Save adds a single session to the response.
 func (s *DynamoStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	return s.SaveContext(r.Context(), r, w, session)
+}
+
+// SaveContext is the context-aware variant of Save. The given context
+// governs the underlying backend Put/Delete call.
+func (s *DynamoStore) SaveContext(ctx context.Context, r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
 	if session.Options.MaxAge <= 0 {
-		if err := s.delete(session); err != nil {
+		if err := s.delete(ctx, session); err != nil {
 			return err
 		}
 		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
@@ -187,7 +230,7 @@ func (s *DynamoStore) Save(r *http.Request, w http.ResponseWriter, session *sess
 		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
 	}
 
-	if err := s.save(session); err != nil {
+	if err := s.save(ctx, session); err != nil {
 		return err
 	}
 
@@ -213,9 +256,21 @@ func (s *DynamoStore) MaxAge(age int) {
 	}
 }
 
-// save writes encoded session.Values into dynamoDB.
-// returns error if there is an error while saving the session in dynamoDB
-func (s *DynamoStore) save(session *sessions.Session) error {
+// contextStore binds a fixed context to a DynamoStore so it can satisfy
+// sessions.Store, whose Get/New methods have no context parameter of their
+// own.
+type contextStore struct {
+	*DynamoStore
+	ctx context.Context
+}
+
+func (c *contextStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return c.DynamoStore.NewContext(c.ctx, r, name)
+}
+
+// save encodes session.Values and writes it to the backend.
+// returns error if there is an error while saving the session
+func (s *DynamoStore) save(ctx context.Context, session *sessions.Session) error {
 	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
 	if err != nil {
 		return err
@@ -225,48 +280,38 @@ func (s *DynamoStore) save(session *sessions.Session) error {
 		ID:         session.ID,
 		Data:       encoded,
 		ModifiedAt: time.Now().Unix(),
+		Version:    sessionVersion(session),
 	}
 
-	if s.ttlEnabled && session.Options != nil && session.Options.MaxAge > 0 {
-		sessionObj.TTL = time.Now().Unix() + int64(session.Options.MaxAge)
+	if userID, ok := session.Values["user_id"].(string); ok {
+		sessionObj.UserID = userID
 	}
 
-	sessionItem, err := dynamodbattribute.MarshalMap(sessionObj)
-	if err != nil {
-		return err
+	if s.TTLEnabled && session.Options != nil && session.Options.MaxAge > 0 {
+		sessionObj.TTL = time.Now().Unix() + int64(session.Options.MaxAge)
 	}
 
-	if _, err = s.client.PutItem(&dynamodb.PutItemInput{
-		Item:      sessionItem,
-		TableName: aws.String(s.table),
-	}); err != nil {
+	if err := s.backend.Put(ctx, sessionObj); err != nil {
 		return err
 	}
 
+	setSessionVersion(session, sessionObj.Version)
 	return nil
 }
 
-// load reads the session from dynamoDB.
-// returns error if session data does not exist in dynamoDB
-func (s *DynamoStore) load(session *sessions.Session) error {
-	input := &dynamodb.GetItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(session.ID),
-			},
-		},
-		ConsistentRead: aws.Bool(true),
-		TableName:      aws.String(s.table),
-	}
-
-	result, err := s.client.GetItem(input)
-	if err != nil {
-		return err
-	}
-
-	var sessionObj Session
-	if err := dynamodbattribute.UnmarshalMap(result.Item, &sessionObj); err != nil {
-		return err
+// load reads the session from the backend, consuming a Preload-warmed
+// entry for this id if the context carries one.
+// returns error if session data does not exist
+func (s *DynamoStore) load(ctx context.Context, session *sessions.Session) error {
+	var sessionObj *Session
+	if cached, ok := takePreloaded(ctx, session.ID); ok {
+		sessionObj = cached
+	} else {
+		var err error
+		sessionObj, err = s.backend.Get(ctx, session.ID)
+		if err != nil {
+			return err
+		}
 	}
 
 	if sessionObj.TTL > 0 && sessionObj.TTL < time.Now().Unix() {
@@ -278,91 +323,13 @@ func (s *DynamoStore) load(session *sessions.Session) error {
 		return err
 	}
 
+	setSessionVersion(session, sessionObj.Version)
 	return nil
 }
 
-// delete removes the session from dynamodb.
-// returns error if there is an error in deletion of session from dynamoDB
-func (s *DynamoStore) delete(session *sessions.Session) error {
-	input := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(session.ID),
-			},
-		},
-		TableName: aws.String(s.table),
-	}
-
-	_, err := s.client.DeleteItem(input)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// createTableIfNotExists creates a DynamoDB table with a given
-// DynamoDB client. If the table already exists, it is not being reconfigured.
-func createTableIfNotExists(client *dynamodb.DynamoDB, table string, readCapacity, writeCapacity int64, ttlEnabled bool) error {
-	_, err := client.DescribeTable(&dynamodb.DescribeTableInput{
-		TableName: aws.String(table),
-	})
-
-	if awserr, ok := err.(awserr.Error); ok {
-		if awserr.Code() == "ResourceNotFoundException" {
-			err = configureTable(client, table, readCapacity, writeCapacity, ttlEnabled)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func configureTable(client *dynamodb.DynamoDB, table string, readCapacity, writeCapacity int64, ttlEnabled bool) error {
-	_, err := client.CreateTable(&dynamodb.CreateTableInput{
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{{
-			AttributeName: aws.String("id"),
-			AttributeType: aws.String("S"),
-		}},
-		KeySchema: []*dynamodb.KeySchemaElement{{
-			AttributeName: aws.String("id"),
-			KeyType:       aws.String("HASH"),
-		}},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(readCapacity),
-			WriteCapacityUnits: aws.Int64(writeCapacity),
-		},
-		TableName: aws.String(table),
-	})
-	if err != nil {
-		return err
-	}
-
-	err = client.WaitUntilTableExists(&dynamodb.DescribeTableInput{
-		TableName: aws.String(table),
-	})
-	if err != nil {
-		return err
-	}
-
-	if !ttlEnabled {
-		return nil
-	}
-
-	_, err = client.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
-		TableName: aws.String(table),
-		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
-			AttributeName: aws.String("ttl"),
-			Enabled:       aws.Bool(true),
-		},
-	})
-	if err != nil {
-		return err
-	}
-
-	return nil
+// delete removes the session from the backend.
+// returns error if there is an error in deletion of the session
+func (s *DynamoStore) delete(ctx context.Context, session *sessions.Session) error {
+	defer clearSessionVersion(session)
+	return s.backend.Delete(ctx, session.ID)
 }
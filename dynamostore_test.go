@@ -25,6 +25,33 @@ func TestNewDynamoStore(t *testing.T) {
 	}
 }
 
+func TestNewDynamoStoreBillingModes(t *testing.T) {
+	tests := []struct {
+		name        string
+		billingMode string
+	}{
+		{"provisioned", BillingModeProvisioned},
+		{"payPerRequest", BillingModePayPerRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDynamoStore(map[string]interface{}{
+				"table":                  fake.CharactersN(10),
+				"region":                 "",
+				"endpoint":               "http://localhost:8000",
+				"ttl_enabled":            false,
+				"billing_mode":           tt.billingMode,
+				"sse_enabled":            true,
+				"point_in_time_recovery": true,
+			}, []byte("secret-key"))
+			if err != nil {
+				t.Errorf("expected nil; got %v", err)
+			}
+		})
+	}
+}
+
 func TestSessionLifecycle(t *testing.T) {
 
 	var req *http.Request
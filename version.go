@@ -0,0 +1,86 @@
+package dynamostore
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrSessionConflict is returned by Save when the session was modified by
+// another request since it was loaded, so the write was rejected instead of
+// silently clobbering the concurrent change.
+var ErrSessionConflict = errors.New("dynamostore: session was modified concurrently")
+
+// ErrBackendNotSupported is returned by DynamoStore operations that require
+// an AdminBackend when the configured backend doesn't implement one.
+var ErrBackendNotSupported = errors.New("dynamostore: backend does not support this operation")
+
+// sessionVersionTTL bounds how long a sessionVersions entry is trusted
+// before it's treated as expired. load() and save() for a given session
+// normally happen within the same request, well inside this window; entries
+// that outlive it (aborted requests, sessions that are never saved again)
+// are swept instead of being retained forever.
+const sessionVersionTTL = 10 * time.Minute
+
+// sessionVersionSweepEvery triggers a sweep of expired entries every this
+// many setSessionVersion calls, bounding sessionVersions' size without
+// requiring a background goroutine.
+const sessionVersionSweepEvery = 1024
+
+// sessionVersions tracks the last-observed Version for each in-flight
+// *sessions.Session, keyed by pointer since gorilla/sessions.Session has no
+// field of its own to carry it. Entries are written by load() and consumed
+// by save(); entries older than sessionVersionTTL are swept periodically so
+// sessions that are never saved again don't accumulate indefinitely.
+var sessionVersions sync.Map // map[*sessions.Session]versionEntry
+
+var sessionVersionStores int64
+
+type versionEntry struct {
+	version   int64
+	expiresAt time.Time
+}
+
+func sessionVersion(session *sessions.Session) int64 {
+	if v, ok := sessionVersions.Load(session); ok {
+		entry := v.(versionEntry)
+		if time.Now().Before(entry.expiresAt) {
+			return entry.version
+		}
+		sessionVersions.Delete(session)
+	}
+	return 0
+}
+
+func setSessionVersion(session *sessions.Session, version int64) {
+	sessionVersions.Store(session, versionEntry{
+		version:   version,
+		expiresAt: time.Now().Add(sessionVersionTTL),
+	})
+
+	if atomic.AddInt64(&sessionVersionStores, 1)%sessionVersionSweepEvery == 0 {
+		sweepExpiredSessionVersions()
+	}
+}
+
+func clearSessionVersion(session *sessions.Session) {
+	sessionVersions.Delete(session)
+}
+
+// sweepExpiredSessionVersions removes entries whose TTL has passed. It's
+// called periodically from setSessionVersion rather than from a background
+// goroutine, so sessionVersions never grows unbounded even for callers that
+// always let sessions expire via cookie MaxAge instead of deleting them with
+// Save and Options.MaxAge <= 0 (the only path that clears an entry directly).
+func sweepExpiredSessionVersions() {
+	now := time.Now()
+	sessionVersions.Range(func(key, value interface{}) bool {
+		if entry, ok := value.(versionEntry); ok && now.After(entry.expiresAt) {
+			sessionVersions.Delete(key)
+		}
+		return true
+	})
+}
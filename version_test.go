@@ -0,0 +1,69 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// conditionalMemoryBackend is memoryBackend plus the same conditional-write
+// semantics as dynamoBackend.Put, so optimistic-concurrency behavior can be
+// exercised without a live DynamoDB.
+type conditionalMemoryBackend struct {
+	memoryBackend
+	mu sync.Mutex
+}
+
+func (b *conditionalMemoryBackend) Put(ctx context.Context, session *Session) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	existing, ok := b.sessions[session.ID]
+	if ok && existing.Version != session.Version {
+		return ErrSessionConflict
+	}
+
+	stored := *session
+	stored.Version = session.Version + 1
+	b.sessions[session.ID] = &stored
+	session.Version = stored.Version
+	return nil
+}
+
+func TestSaveSurfacesSessionConflict(t *testing.T) {
+	backend := &conditionalMemoryBackend{memoryBackend: *newMemoryBackend()}
+	store := NewStore(backend, []byte("secret-key"))
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "mysession")
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	session.Values["name"] = "alice"
+	if err := session.Save(req, res); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	req.AddCookie(res.Result().Cookies()[0])
+
+	// Simulate a concurrent writer bumping the stored version from under us.
+	stale, err := backend.Get(req.Context(), session.ID)
+	if err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+	if err := backend.Put(req.Context(), &Session{ID: stale.ID, Data: stale.Data, Version: stale.Version}); err != nil {
+		t.Fatalf("expected nil; got %v", err)
+	}
+
+	// Our in-hand session still carries the version we loaded before the
+	// concurrent write above, so saving it again must be rejected.
+	session.Values["name"] = "bob"
+	if err := session.Save(req, res); !errors.Is(err, ErrSessionConflict) {
+		t.Errorf("expected ErrSessionConflict; got %v", err)
+	}
+}